@@ -0,0 +1,130 @@
+// bind.go
+//
+// Struct-tag based option registration, for those who would rather
+// describe their options once, on the struct itself, than make a
+// separate AddXxx() call for each one.
+//
+package dconfig
+
+import("errors"; "fmt"; "reflect"; "strings")
+
+const tag_name string = "dconf"
+
+// Parses the content of a single `dconf:"..."` struct tag into an
+// option name and a set of flags. A token of "name=xxx" sets the name
+// explicitly; a bare token is used as the name if one hasn't been set
+// yet; the tokens "strip", "upper", "lower", and "unsigned" set the
+// corresponding flag.
+//
+func parseDconfTag(tag string) (string, uint8, error) {
+    var name string
+    var flags uint8
+
+    for _, token := range strings.Split(tag, ",") {
+        token = strings.TrimSpace(token)
+        if token == "" {
+            continue
+        }
+
+        switch {
+        case strings.HasPrefix(token, "name="):
+            name = token[len("name="):]
+        case token == "strip":
+            flags = flags | STRIP
+        case token == "upper":
+            flags = flags | UPPER
+        case token == "lower":
+            flags = flags | LOWER
+        case token == "unsigned":
+            flags = flags | UNSIGNED
+        case name == "":
+            name = token
+        default:
+            return "", 0, errors.New(fmt.Sprintf(
+                "unrecognized dconf tag token: %q", token))
+        }
+    }
+
+    if name == "" {
+        return "", 0, errors.New("dconf tag specifies no option name")
+    }
+
+    return name, flags, nil
+}
+
+// Bind() reflects over target, which must be a pointer to a struct, and
+// registers an option on this Config for each exported field bearing a
+// `dconf` struct tag, e.g.
+//
+//  type Conf struct {
+//      Port  int    `dconf:"name=port,unsigned"`
+//      Debug bool   `dconf:"true_or_false"`
+//  }
+//
+// Field types of int, string, float64, and bool are mapped onto
+// AddInt(), AddString(), AddFloat(), and AddBool() respectively; the
+// flag keywords "strip", "upper", "lower", and "unsigned" in the tag
+// translate to the STRIP/UPPER/LOWER/UNSIGNED flags. Fields without a
+// `dconf` tag are ignored.
+//
+func (c *Config) Bind(target interface{}) error {
+    val := reflect.ValueOf(target)
+    if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+        return errors.New("Bind() requires a pointer to a struct")
+    }
+
+    struct_val := val.Elem()
+    struct_typ := struct_val.Type()
+
+    for i := 0; i < struct_typ.NumField(); i++ {
+        field_typ := struct_typ.Field(i)
+        tag, ok := field_typ.Tag.Lookup(tag_name)
+        if !ok {
+            continue
+        }
+        if field_typ.PkgPath != "" {
+            return errors.New(fmt.Sprintf(
+                "field %q is unexported and cannot be bound", field_typ.Name))
+        }
+
+        name, flags, err := parseDconfTag(tag)
+        if err != nil {
+            return errors.New(fmt.Sprintf(
+                "field %q: %s", field_typ.Name, err))
+        }
+
+        field_val := struct_val.Field(i)
+        if !field_val.CanAddr() {
+            return errors.New(fmt.Sprintf(
+                "field %q is not addressable", field_typ.Name))
+        }
+
+        switch field_val.Kind() {
+        case reflect.Int:
+            err = c.AddInt(field_val.Addr().Interface().(*int), name, flags)
+        case reflect.String:
+            err = c.AddString(field_val.Addr().Interface().(*string), name, flags)
+        case reflect.Float64:
+            err = c.AddFloat(field_val.Addr().Interface().(*float64), name, flags)
+        case reflect.Bool:
+            err = c.AddBool(field_val.Addr().Interface().(*bool), name)
+        default:
+            err = errors.New(fmt.Sprintf(
+                "field %q has unsupported type %s", field_typ.Name, field_val.Kind()))
+        }
+
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// Bind() reflects over target, which must be a pointer to a struct, and
+// registers an option on the default Config for each exported field
+// bearing a `dconf` struct tag. See (*Config).Bind() for details.
+//
+func Bind(target interface{}) error {
+    return default_config.Bind(target)
+}
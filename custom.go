@@ -0,0 +1,153 @@
+// custom.go
+//
+// AddCustom() lets callers register option types dconfig doesn't know
+// about natively, by supplying their own parsing function. A handful of
+// useful custom types -- string slices, durations, and path lists -- are
+// provided on top of it.
+//
+// Unlike the built-in INT/STRING/FLOAT/BOOL options, a custom option's
+// key is allowed to appear more than once in a configuration file; each
+// occurrence simply calls the parse function again; a parse function
+// that appends to a slice (as AddStringSlice() and AddPathList() both
+// do) accumulates values across lines instead of clobbering them.
+// (AddDuration() stores a single value, so its last occurrence wins.)
+//
+package dconfig
+
+import("errors"; "fmt"; "os"; "path/filepath"; "strings"; "time")
+
+// customOption holds the parser registered by AddCustom(), plus any
+// STRIP/UPPER/LOWER-style flags that apply before the parser sees the
+// raw value.
+//
+type customOption struct {
+    parse func(string) error
+    flags uint8
+}
+
+// AddCustom() registers an option of an arbitrary type. parse is called
+// with the raw string found on the right-hand side of "NAME=..." each
+// time NAME is encountered in a configuration file (or set via an
+// environment variable); it is responsible for interpreting that string
+// and storing the result wherever the caller likes. flags may include
+// STRIP (to trim whitespace from the raw value before parse sees it);
+// the other builtin flags (UPPER, LOWER, UNSIGNED) don't apply to custom
+// options and are ignored.
+//
+// Unlike AddInt()/AddString()/AddFloat()/AddBool(), the same option key
+// may be set more than once in a config file; parse will simply be
+// called once per occurrence.
+//
+func (c *Config) AddCustom(name string, parse func(raw string) error, flags uint8) error {
+    uname := strings.ToUpper(name)
+    if c.optionExists(uname) {
+        return errors.New(fmt.Sprintf("\"%s\" option already exists", uname))
+    }
+
+    c.custom_map[uname] = &customOption{parse: parse, flags: flags}
+    c.order = append(c.order, uname)
+
+    return nil
+}
+
+// AddCustom() registers an option of an arbitrary type with the default
+// Config. See (*Config).AddCustom() for details.
+//
+func AddCustom(name string, parse func(raw string) error, flags uint8) error {
+    return default_config.AddCustom(name, parse, flags)
+}
+
+// AddStringSlice() registers an option that splits each occurrence's
+// raw value on sep and appends the resulting (non-empty) tokens to
+// *target. flags may include STRIP, UPPER, and LOWER, which are applied
+// to each token individually.
+//
+func (c *Config) AddStringSlice(target *[]string, name, sep string, flags uint8) error {
+    parse := func(raw string) error {
+        for _, tok := range strings.Split(raw, sep) {
+            if hasAttr(flags, STRIP) {
+                tok = strings.TrimSpace(tok)
+            }
+            if tok == "" {
+                continue
+            }
+            if hasAttr(flags, LOWER) {
+                tok = strings.ToLower(tok)
+            } else if hasAttr(flags, UPPER) {
+                tok = strings.ToUpper(tok)
+            }
+            *target = append(*target, tok)
+        }
+        return nil
+    }
+
+    return c.AddCustom(name, parse, NONE)
+}
+
+// AddStringSlice() registers an option that splits each occurrence's
+// raw value on sep and appends the resulting tokens to *target, using
+// the default Config. See (*Config).AddStringSlice() for details.
+//
+func AddStringSlice(target *[]string, name, sep string, flags uint8) error {
+    return default_config.AddStringSlice(target, name, sep, flags)
+}
+
+// AddDuration() registers an option that parses each occurrence's raw
+// value with time.ParseDuration() and stores the result in *target.
+//
+func (c *Config) AddDuration(target *time.Duration, name string) error {
+    parse := func(raw string) error {
+        d, err := time.ParseDuration(strings.TrimSpace(raw))
+        if err != nil {
+            return errors.New(fmt.Sprintf(
+                "\"%s\" not a recognizable duration", raw))
+        }
+        *target = d
+        return nil
+    }
+
+    return c.AddCustom(name, parse, NONE)
+}
+
+// AddDuration() registers an option that parses each occurrence's raw
+// value as a time.Duration, using the default Config. See
+// (*Config).AddDuration() for details.
+//
+func AddDuration(target *time.Duration, name string) error {
+    return default_config.AddDuration(target, name)
+}
+
+// AddPathList() registers an option that splits each occurrence's raw
+// value on the platform's path list separator (":" on Unix, ";" on
+// Windows -- see os.PathListSeparator), expands a leading "~" to the
+// current user's home directory, and appends the results to *target.
+//
+func (c *Config) AddPathList(target *[]string, name string) error {
+    sep := string(os.PathListSeparator)
+
+    parse := func(raw string) error {
+        for _, p := range strings.Split(raw, sep) {
+            p = strings.TrimSpace(p)
+            if p == "" {
+                continue
+            }
+            if p == "~" || strings.HasPrefix(p, "~/") {
+                if home, err := os.UserHomeDir(); err == nil {
+                    p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+                }
+            }
+            *target = append(*target, p)
+        }
+        return nil
+    }
+
+    return c.AddCustom(name, parse, NONE)
+}
+
+// AddPathList() registers an option that splits each occurrence's raw
+// value into a list of paths, using the default Config. See
+// (*Config).AddPathList() for details.
+//
+func AddPathList(target *[]string, name string) error {
+    return default_config.AddPathList(target, name)
+}
@@ -7,9 +7,19 @@
 // operation and the format of the API function calls, breaking all programs
 // written before then. I'm sorry for your loss, but it works better now.
 //
+// Updated 2018-11-03
+//
+// Options are now tracked by a *Config instance rather than by bare
+// package-level maps. This means two packages (or two parts of the same
+// program) that both use dconfig no longer stomp on each other's keys
+// just because they share a process. The package-level AddXxx(),
+// Configure(), Reset(), and OptionType() functions still work exactly as
+// before; they now operate on a default *Config kept internally by the
+// package, so existing programs require no changes.
+//
 package dconfig
 
-import("bufio"; "errors"; "fmt"; "os"; "regexp"; "strconv"; "strings")
+import("bufio"; "errors"; "fmt"; "os"; "regexp"; "strconv"; "strings"; "sync")
 
 // STRIP is passed as a flag to AddString() to indicate that whitespace
 // should be trimmed from the ends of the value when read from a
@@ -44,17 +54,19 @@ const (
     FLOAT   uint8 = 32
 )
 
+// CUSTOM is returned by OptionType() to indicate an option registered
+// via AddCustom() (or one of the built-in custom types, such as
+// AddStringSlice()). It does not occupy its own bit in the flags
+// bitmask the way the other type constants do -- custom options are
+// tracked separately and dispatched before the bitmask is consulted --
+// so it's only meaningful as an OptionType() return value.
+const CUSTOM uint8 = 255
+
 const all_types uint8 = STRING | INT | FLOAT | BOOL
 const disallowed_str_opts   uint8 = UNSIGNED
 const disallowed_int_opts   uint8 = STRIP | UPPER | LOWER
 const disallowed_float_opts uint8 = STRIP | UPPER | LOWER
 
-var str_map      map[string]*string
-var int_map      map[string]*int
-var float_map    map[string]*float64
-var bool_map     map[string]*bool
-var option_flags map[string]uint8
-
 var comment_re   *regexp.Regexp
 var nonblank_re  *regexp.Regexp
 var option_re    *regexp.Regexp
@@ -66,6 +78,37 @@ var ufloat_token *regexp.Regexp
 var boolean_trues  [6]string = [6]string{"1", "t", "true", "y", "yes", "+"}
 var boolean_falses [7]string = [7]string{"0", "f", "false", "n", "no", "-", "nil"}
 
+// A Config holds a set of registered options and their current values.
+// Programs that only need a single, process-wide set of options (the
+// common case) can ignore Config entirely and use the package-level
+// AddXxx()/Configure()/Reset()/OptionType() functions, which operate on
+// a default *Config. Programs that need more than one independent set
+// of options (for instance, a library that uses dconfig internally and
+// doesn't want to collide with its caller's options) can call
+// NewConfig() and use the methods below instead.
+//
+type Config struct {
+    mu           sync.RWMutex
+    str_map      map[string]*string
+    int_map      map[string]*int
+    float_map    map[string]*float64
+    bool_map     map[string]*bool
+    custom_map   map[string]*customOption
+    option_flags map[string]uint8
+    doc_map      map[string]string
+    order        []string
+}
+
+// NewConfig() returns a freshly initialized, empty *Config.
+//
+func NewConfig() *Config {
+    c := &Config{}
+    c.Reset()
+    return c
+}
+
+var default_config *Config = NewConfig()
+
 // Return true if value val has the bit for attribute attrib set.
 //
 func hasAttr(val, attrib uint8) bool {
@@ -110,7 +153,20 @@ func sumOfBits(bmask uint8) uint8 {
     return sum
 }
 
-// Reset() clears all the configured options.
+// Reset() clears all of this Config's registered options.
+//
+func (c *Config) Reset() {
+    c.str_map = make(map[string]*string)
+    c.int_map = make(map[string]*int)
+    c.float_map = make(map[string]*float64)
+    c.bool_map = make(map[string]*bool)
+    c.custom_map = make(map[string]*customOption)
+    c.option_flags = make(map[string]uint8)
+    c.doc_map = make(map[string]string)
+    c.order = nil
+}
+
+// Reset() clears all the configured options from the default Config.
 // If a package your program uses ALSO uses package dconfig, and they have one
 // or more identical keys, this could cause weird behavior. To avoid this,
 // your program should
@@ -119,42 +175,56 @@ func sumOfBits(bmask uint8) uint8 {
 // * call dconfig.Configure()
 // without doing anything else in between.
 //
+// Better still, if you're worried about this kind of collision, give
+// each package its own *Config via NewConfig() so there's nothing to
+// collide over in the first place.
+//
 func Reset() {
-    str_map = make(map[string]*string)
-    int_map = make(map[string]*int)
-    float_map = make(map[string]*float64)
-    bool_map = make(map[string]*bool)
-    option_flags = make(map[string]uint8)
+    default_config.Reset()
 }
 
 // OptionType() returns the type of value associated with a given option
-// name. Returned are one of the aforementioned constants (STRING, INT,
-// FLOAT, BOOL); returns the constant NONE if opt isn't configured.
+// name in this Config. Returned are one of the aforementioned constants
+// (STRING, INT, FLOAT, BOOL, CUSTOM); returns the constant NONE if opt
+// isn't configured.
 //
-func OptionType(opt string) uint8 {
+func (c *Config) OptionType(opt string) uint8 {
     var exists bool
     uname := strings.ToUpper(opt)
-    _, exists = str_map[uname]
+    _, exists = c.str_map[uname]
     if exists {
         return STRING
     }
-    _, exists = int_map[uname]
+    _, exists = c.int_map[uname]
     if exists {
         return INT
     }
-    _, exists = float_map[uname]
+    _, exists = c.float_map[uname]
     if exists {
         return FLOAT
     }
-    _, exists = bool_map[uname]
+    _, exists = c.bool_map[uname]
     if exists {
         return BOOL
     }
+    _, exists = c.custom_map[uname]
+    if exists {
+        return CUSTOM
+    }
     return NONE
 }
 
-func optionExists(opt string) bool {
-    return OptionType(opt) != NONE
+// OptionType() returns the type of value associated with a given option
+// name in the default Config. Returned are one of the aforementioned
+// constants (STRING, INT, FLOAT, BOOL, CUSTOM); returns the constant NONE
+// if opt isn't configured.
+//
+func OptionType(opt string) uint8 {
+    return default_config.OptionType(opt)
+}
+
+func (c *Config) optionExists(opt string) bool {
+    return c.OptionType(opt) != NONE
 }
 
 // Adds an option that will be parsed as an integer when read from the
@@ -163,22 +233,33 @@ func optionExists(opt string) bool {
 // It can take the NONE flag, or the UNSIGNED flag (in which case any
 // leading minus signs will be ignored when converting into an int).
 //
-func AddInt(target *int, name string, flags uint8) error {
+func (c *Config) AddInt(target *int, name string, flags uint8) error {
     if flags & disallowed_int_opts != 0 {
         return errors.New("unsupported flag for integer option type")
     }
-    
+
     uname := strings.ToUpper(name)
-    if optionExists(uname) {
+    if c.optionExists(uname) {
         return errors.New(fmt.Sprintf("\"%s\" option already exists", uname))
     }
-    
-    int_map[uname] = target
-    option_flags[uname] = flags | INT
-    
+
+    c.int_map[uname] = target
+    c.option_flags[uname] = flags | INT
+    c.order = append(c.order, uname)
+
     return nil
 }
 
+// Adds an option that will be parsed as an integer when read from the
+// configuration file, using the default Config.
+//
+// It can take the NONE flag, or the UNSIGNED flag (in which case any
+// leading minus signs will be ignored when converting into an int).
+//
+func AddInt(target *int, name string, flags uint8) error {
+    return default_config.AddInt(target, name, flags)
+}
+
 // Adds an option that will be parsed as a string when read from the
 // configuration file.
 //
@@ -189,66 +270,115 @@ func AddInt(target *int, name string, flags uint8) error {
 //
 // Don't use the last two together.
 //
-func AddString(target *string, name string, flags uint8) error {
+func (c *Config) AddString(target *string, name string, flags uint8) error {
     if flags & disallowed_str_opts != 0 {
         return errors.New("unsupported flag for string option type")
     }
 
     uname := strings.ToUpper(name)
-    if optionExists(uname) {
+    if c.optionExists(uname) {
         return errors.New(fmt.Sprintf("\"%s\" option already exists", uname))
     }
-    
-    str_map[uname] = target
-    option_flags[uname] = flags | STRING
-    
+
+    c.str_map[uname] = target
+    c.option_flags[uname] = flags | STRING
+    c.order = append(c.order, uname)
+
     return nil
 }
 
+// Adds an option that will be parsed as a string when read from the
+// configuration file, using the default Config.
+//
+// In addition to the NONE flag, it can take a combination of the following:
+//  * STRIP -- leading and trailing whitespace will be trimmed
+//  * UPPER -- will be converted to upper case
+//  * LOWER -- will be converted to lower case
+//
+// Don't use the last two together.
+//
+func AddString(target *string, name string, flags uint8) error {
+    return default_config.AddString(target, name, flags)
+}
+
 // Adds an option that will be parsed as a floating point number when
 // read from the configuration file.
 //
 // It can take the NONE flag, or the UNSIGNED flag (in which case any
 // leading minus sign will be ignored when converting into a float).
 //
-func AddFloat(target *float64, name string, flags uint8) error {
+func (c *Config) AddFloat(target *float64, name string, flags uint8) error {
     if flags & disallowed_float_opts != 0 {
         return errors.New("unsupported flag for float option type")
     }
 
     uname := strings.ToUpper(name)
-    if optionExists(uname) {
+    if c.optionExists(uname) {
         return errors.New(fmt.Sprintf("\"%s\" option already exists", uname))
     }
-    
-    float_map[uname] = target
-    option_flags[uname] = flags | FLOAT
-    
+
+    c.float_map[uname] = target
+    c.option_flags[uname] = flags | FLOAT
+    c.order = append(c.order, uname)
+
     return nil
 }
 
+// Adds an option that will be parsed as a floating point number when
+// read from the configuration file, using the default Config.
+//
+// It can take the NONE flag, or the UNSIGNED flag (in which case any
+// leading minus sign will be ignored when converting into a float).
+//
+func AddFloat(target *float64, name string, flags uint8) error {
+    return default_config.AddFloat(target, name, flags)
+}
+
 // Adds an option that will be parsed as a boolean when read from the
 // configuration file. Accepts many varieties of true/false representations.
 //
-func AddBool(target *bool, name string) error {
+func (c *Config) AddBool(target *bool, name string) error {
     uname := strings.ToUpper(name)
-    if optionExists(uname) {
+    if c.optionExists(uname) {
         return errors.New(fmt.Sprintf("\"%s\" option already exists", uname))
     }
-    
-    bool_map[uname] = target
-    option_flags[uname] = BOOL
-    
+
+    c.bool_map[uname] = target
+    c.option_flags[uname] = BOOL
+    c.order = append(c.order, uname)
+
     return nil
 }
 
-// setOption() is called by Configure() fore each line that matches the
+// Adds an option that will be parsed as a boolean when read from the
+// configuration file, using the default Config. Accepts many varieties
+// of true/false representations.
+//
+func AddBool(target *bool, name string) error {
+    return default_config.AddBool(target, name)
+}
+
+// setOption() is called by Configure() for each line that matches the
 // OPTION=value pattern. It updates the appropriate xxx_map[] for each
 // extant OPTION with a well-formed value.
 //
-func setOption(name, value string, verbose bool) error {
+func (c *Config) setOption(name, value string, verbose bool) error {
     uname := strings.ToUpper(name)
-    flags, exists := option_flags[uname]
+
+    if custom, exists := c.custom_map[uname]; exists {
+        if hasAttr(custom.flags, STRIP) {
+            value = strings.TrimSpace(value)
+        }
+        if err := custom.parse(value); err != nil {
+            if verbose {
+                fmt.Fprintf(os.Stderr, "%s\n", err)
+            }
+            return err
+        }
+        return nil
+    }
+
+    flags, exists := c.option_flags[uname]
     if !exists {
         err_str := fmt.Sprintf("unrecognized option \"%s\"", uname)
         if verbose {
@@ -256,7 +386,7 @@ func setOption(name, value string, verbose bool) error {
         }
         return errors.New(err_str)
     }
-    
+
     if hasAttr(flags, STRING) {
         if hasAttr(flags, STRIP) {
             value = strings.TrimSpace(value)
@@ -266,9 +396,9 @@ func setOption(name, value string, verbose bool) error {
         } else if hasAttr(flags, UPPER) {
             value = strings.ToUpper(value)
         }
-        *(str_map[uname]) = value
+        *(c.str_map[uname]) = value
         return nil
-        
+
     } else if hasAttr(flags, INT) {
         if hasAttr(flags, UNSIGNED) {
             value = uint_token.FindString(value)
@@ -283,9 +413,9 @@ func setOption(name, value string, verbose bool) error {
             }
             return errors.New(err_str)
         }
-        *(int_map[uname]) = iv
+        *(c.int_map[uname]) = iv
         return nil
-        
+
     } else if hasAttr(flags, FLOAT) {
         if hasAttr(flags, UNSIGNED) {
             value = ufloat_token.FindString(value)
@@ -300,21 +430,21 @@ func setOption(name, value string, verbose bool) error {
             }
             return errors.New(err_str)
         }
-        *(float_map[uname]) = fv
+        *(c.float_map[uname]) = fv
         return nil
-        
+
     } else if hasAttr(flags, BOOL) {
         value = strings.TrimSpace(value)
         value = strings.ToLower(value)
         for _, t := range boolean_trues {
             if value == t {
-                *(bool_map[uname]) = true
+                *(c.bool_map[uname]) = true
                 return nil
             }
         }
         for _, f := range boolean_falses {
             if value == f {
-                *(bool_map[uname]) = false
+                *(c.bool_map[uname]) = false
                 return nil
             }
         }
@@ -323,7 +453,7 @@ func setOption(name, value string, verbose bool) error {
             fmt.Fprintf(os.Stderr, "%s\n", err_str)
         }
         return errors.New(err_str)
-        
+
     } else {
         err_str := fmt.Sprintf("some logical error has led us here: %s=%s",
                                uname, value)
@@ -341,7 +471,7 @@ func setOption(name, value string, verbose bool) error {
 // it finds. The verbose argument controls whether processing errors
 // are written to stdout.
 //
-func Configure(files []string, verbose bool) error {   
+func (c *Config) Configure(files []string, verbose bool) error {
     var cfg_file string
     for _, fname := range files {
         if _, err := os.Stat(fname); err == nil {
@@ -352,7 +482,13 @@ func Configure(files []string, verbose bool) error {
     if cfg_file == "" {
         return errors.New("no configuration files found")
     }
-    
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.configureFile(cfg_file, verbose)
+}
+
+func (c *Config) configureFile(cfg_file string, verbose bool) error {
     file, err := os.Open(cfg_file)
     if err != nil {
         err_str := fmt.Sprintf("error opening \"%s\"", cfg_file)
@@ -362,7 +498,7 @@ func Configure(files []string, verbose bool) error {
         return errors.New(err_str)
     }
     defer file.Close()
-    
+
     scanner := bufio.NewScanner(file)
     for scanner.Scan() {
         line := scanner.Text()
@@ -371,20 +507,61 @@ func Configure(files []string, verbose bool) error {
         } else if !nonblank_re.MatchString(line) {
             continue
         }
-        
+
         matches := option_re.FindStringSubmatch(line)
         if matches == nil {
             if verbose {
                 fmt.Fprintf(os.Stderr, "ignoring malformed line: \"%s\"\n", line)
             }
         } else {
-            setOption(matches[1], matches[2], verbose)
+            c.setOption(matches[1], matches[2], verbose)
         }
     }
-    
+
     return nil
 }
 
+// Configure() reads a configuration file, setting the values of any
+// configured variables to those found in the configuration file, using
+// the default Config. The files argument is a slice of paths to
+// possible configuration files; Configure() seeks them in order and
+// processes the first one it finds. The verbose argument controls
+// whether processing errors are written to stderr.
+//
+func Configure(files []string, verbose bool) error {
+    return default_config.Configure(files, verbose)
+}
+
+// RLock() takes a read lock on this Config, so that a caller can take a
+// consistent snapshot across several options without a concurrent
+// Configure()/ConfigureAll() (triggered, for instance, by Watch())
+// changing some of them partway through. Must be paired with a call to
+// RUnlock().
+//
+func (c *Config) RLock() {
+    c.mu.RLock()
+}
+
+// RUnlock() releases a read lock taken with RLock().
+//
+func (c *Config) RUnlock() {
+    c.mu.RUnlock()
+}
+
+// RLock() takes a read lock on the default Config. See (*Config).RLock()
+// for details.
+//
+func RLock() {
+    default_config.RLock()
+}
+
+// RUnlock() releases a read lock on the default Config taken with
+// RLock().
+//
+func RUnlock() {
+    default_config.RUnlock()
+}
+
 func init() {
     comment_re = regexp.MustCompile(`^\s*#`)
     nonblank_re = regexp.MustCompile(`\S`)
@@ -393,6 +570,4 @@ func init() {
     uint_token = regexp.MustCompile(`\d+`)
     float_token = regexp.MustCompile(`-?[0-9.]+`)
     ufloat_token = regexp.MustCompile(`[0-9.]+`)
-    
-    Reset()
 }
@@ -0,0 +1,76 @@
+// env.go
+//
+// Multi-file merging and an environment-variable overlay for Configure().
+//
+package dconfig
+
+import("errors"; "os"; "strings")
+
+// ConfigureAll() reads every file in files that exists, in order, each
+// one overriding values set by the ones before it (rather than stopping
+// at the first one found, as Configure() does). If env_prefix is not
+// the empty string, it then consults the environment for variables
+// named "PREFIX_OPTION_NAME" (e.g. an option named "integer_value" with
+// env_prefix "myapp" is overridden by $MYAPP_INTEGER_VALUE), which take
+// precedence over anything read from files. The verbose argument
+// controls whether processing errors are written to stderr.
+//
+// An error is returned only if none of files exists; malformed or
+// unreadable files are skipped (noisily, if verbose).
+//
+func (c *Config) ConfigureAll(files []string, verbose bool, env_prefix string) error {
+    found := false
+    for _, fname := range files {
+        if _, err := os.Stat(fname); err != nil {
+            continue
+        }
+        found = true
+    }
+    if !found {
+        return errors.New("no configuration files found")
+    }
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for _, fname := range files {
+        if _, err := os.Stat(fname); err != nil {
+            continue
+        }
+        c.configureFile(fname, verbose)
+    }
+
+    if env_prefix != "" {
+        c.configureEnv(env_prefix, verbose)
+    }
+
+    return nil
+}
+
+// ConfigureAll() reads every file in files that exists, in order, and
+// then applies an environment-variable overlay, using the default
+// Config. See (*Config).ConfigureAll() for details.
+//
+func ConfigureAll(files []string, verbose bool, env_prefix string) error {
+    return default_config.ConfigureAll(files, verbose, env_prefix)
+}
+
+// configureEnv() overrides any registered option (built-in or custom)
+// whose corresponding "PREFIX_OPTION_NAME" environment variable is set.
+//
+func (c *Config) configureEnv(env_prefix string, verbose bool) {
+    uprefix := strings.ToUpper(env_prefix)
+
+    for uname := range c.option_flags {
+        envname := uprefix + "_" + uname
+        if value, ok := os.LookupEnv(envname); ok {
+            c.setOption(uname, value, verbose)
+        }
+    }
+    for uname := range c.custom_map {
+        envname := uprefix + "_" + uname
+        if value, ok := os.LookupEnv(envname); ok {
+            c.setOption(uname, value, verbose)
+        }
+    }
+}
@@ -0,0 +1,212 @@
+// template.go
+//
+// WriteTemplate() emits a starter configuration file, documenting each
+// registered option, from the metadata dconfig already tracks. The
+// AddXxxDoc() variants let callers attach a description string to an
+// option so WriteTemplate() has something useful to say about it.
+//
+package dconfig
+
+import("fmt"; "io"; "strings")
+
+// AddIntDoc() is identical to AddInt(), but additionally records doc as
+// a description of the option, to be used by WriteTemplate().
+//
+func (c *Config) AddIntDoc(target *int, name string, flags uint8, doc string) error {
+    if err := c.AddInt(target, name, flags); err != nil {
+        return err
+    }
+    c.doc_map[strings.ToUpper(name)] = doc
+    return nil
+}
+
+// AddIntDoc() registers a documented integer option with the default
+// Config. See (*Config).AddIntDoc() for details.
+//
+func AddIntDoc(target *int, name string, flags uint8, doc string) error {
+    return default_config.AddIntDoc(target, name, flags, doc)
+}
+
+// AddStringDoc() is identical to AddString(), but additionally records
+// doc as a description of the option, to be used by WriteTemplate().
+//
+func (c *Config) AddStringDoc(target *string, name string, flags uint8, doc string) error {
+    if err := c.AddString(target, name, flags); err != nil {
+        return err
+    }
+    c.doc_map[strings.ToUpper(name)] = doc
+    return nil
+}
+
+// AddStringDoc() registers a documented string option with the default
+// Config. See (*Config).AddStringDoc() for details.
+//
+func AddStringDoc(target *string, name string, flags uint8, doc string) error {
+    return default_config.AddStringDoc(target, name, flags, doc)
+}
+
+// AddFloatDoc() is identical to AddFloat(), but additionally records
+// doc as a description of the option, to be used by WriteTemplate().
+//
+func (c *Config) AddFloatDoc(target *float64, name string, flags uint8, doc string) error {
+    if err := c.AddFloat(target, name, flags); err != nil {
+        return err
+    }
+    c.doc_map[strings.ToUpper(name)] = doc
+    return nil
+}
+
+// AddFloatDoc() registers a documented float option with the default
+// Config. See (*Config).AddFloatDoc() for details.
+//
+func AddFloatDoc(target *float64, name string, flags uint8, doc string) error {
+    return default_config.AddFloatDoc(target, name, flags, doc)
+}
+
+// AddBoolDoc() is identical to AddBool(), but additionally records doc
+// as a description of the option, to be used by WriteTemplate().
+//
+func (c *Config) AddBoolDoc(target *bool, name string, doc string) error {
+    if err := c.AddBool(target, name); err != nil {
+        return err
+    }
+    c.doc_map[strings.ToUpper(name)] = doc
+    return nil
+}
+
+// AddBoolDoc() registers a documented bool option with the default
+// Config. See (*Config).AddBoolDoc() for details.
+//
+func AddBoolDoc(target *bool, name string, doc string) error {
+    return default_config.AddBoolDoc(target, name, doc)
+}
+
+// typeLabel() returns the human-readable name of one of the
+// STRING/INT/FLOAT/BOOL/CUSTOM type constants, for use in
+// WriteTemplate()'s comments.
+//
+func typeLabel(typ uint8) string {
+    switch typ {
+    case INT:
+        return "int"
+    case STRING:
+        return "string"
+    case FLOAT:
+        return "float"
+    case BOOL:
+        return "bool"
+    case CUSTOM:
+        return "custom"
+    }
+    return "unknown"
+}
+
+// flagNames() returns the names of the STRIP/UPPER/LOWER/UNSIGNED flags
+// set in flags that are meaningful for an option of the given type.
+//
+func flagNames(flags, typ uint8) []string {
+    var names []string
+    if typ == STRING || typ == CUSTOM {
+        if hasAttr(flags, STRIP) {
+            names = append(names, "strip")
+        }
+    }
+    if typ == STRING {
+        if hasAttr(flags, UPPER) {
+            names = append(names, "upper")
+        }
+        if hasAttr(flags, LOWER) {
+            names = append(names, "lower")
+        }
+    }
+    if typ == INT || typ == FLOAT {
+        if hasAttr(flags, UNSIGNED) {
+            names = append(names, "unsigned")
+        }
+    }
+    return names
+}
+
+// writeOptionTemplate() writes the "# NAME (type, flags)" header, an
+// optional doc comment, and a "NAME=value" line for a single option.
+//
+func (c *Config) writeOptionTemplate(w io.Writer, uname string) error {
+    typ := c.OptionType(uname)
+    label := typeLabel(typ)
+
+    flags := c.option_flags[uname]
+    if typ == CUSTOM {
+        flags = c.custom_map[uname].flags
+    }
+
+    header := fmt.Sprintf("# %s (%s)", uname, label)
+    if names := flagNames(flags, typ); len(names) > 0 {
+        header = fmt.Sprintf("# %s (%s, %s)", uname, label, strings.Join(names, ","))
+    }
+    if _, err := fmt.Fprintln(w, header); err != nil {
+        return err
+    }
+    if doc, ok := c.doc_map[uname]; ok && doc != "" {
+        if _, err := fmt.Fprintf(w, "# %s\n", doc); err != nil {
+            return err
+        }
+    }
+
+    var line string
+    switch typ {
+    case STRING:
+        line = fmt.Sprintf("%s=%s", uname, *(c.str_map[uname]))
+    case INT:
+        line = fmt.Sprintf("%s=%d", uname, *(c.int_map[uname]))
+    case FLOAT:
+        line = fmt.Sprintf("%s=%v", uname, *(c.float_map[uname]))
+    case BOOL:
+        line = fmt.Sprintf("%s=%t", uname, *(c.bool_map[uname]))
+    default:
+        line = fmt.Sprintf("#%s=", uname)
+    }
+
+    _, err := fmt.Fprintf(w, "%s\n\n", line)
+    return err
+}
+
+// WriteTemplate() writes a sample configuration file to w, documenting
+// every option registered on this Config: a "# NAME (type, flags)"
+// comment (plus the option's doc string, if it was registered with one
+// of the AddXxxDoc() variants), followed by a "NAME=<current value>"
+// line, grouped by type and in registration order within each group.
+// Options with no sensible default to print (currently, those added
+// with AddCustom() and its built-in variants) get a commented-out
+// "#NAME=" line instead.
+//
+func (c *Config) WriteTemplate(w io.Writer) error {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+
+    for _, typ := range []uint8{INT, STRING, FLOAT, BOOL, CUSTOM} {
+        wrote_header := false
+        for _, uname := range c.order {
+            if c.OptionType(uname) != typ {
+                continue
+            }
+            if !wrote_header {
+                if _, err := fmt.Fprintf(w, "# -- %s options --\n\n", typeLabel(typ)); err != nil {
+                    return err
+                }
+                wrote_header = true
+            }
+            if err := c.writeOptionTemplate(w, uname); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// WriteTemplate() writes a sample configuration file documenting every
+// option registered on the default Config. See (*Config).WriteTemplate()
+// for details.
+//
+func WriteTemplate(w io.Writer) error {
+    return default_config.WriteTemplate(w)
+}
@@ -0,0 +1,121 @@
+// watch.go
+//
+// Watch() turns dconfig from a one-shot loader into something usable by
+// a long-running daemon: it watches the configuration files for changes
+// and re-runs the parsing pipeline whenever one is written, so a
+// program can pick up new option values without restarting.
+//
+package dconfig
+
+import("errors"; "fmt"; "os"; "path/filepath"; "github.com/fsnotify/fsnotify")
+
+// Watch() monitors files and calls c.ConfigureAll(files, verbose,
+// env_prefix) every time one of them changes, updating the
+// pointer-backed option values in place -- this keeps reload behaving
+// exactly like the initial load, env-var overlay included. Each such
+// update is done under this Config's write lock (see RLock()/RUnlock()),
+// so callers reading several options together should bracket those
+// reads with RLock()/RUnlock() to see a consistent snapshot.
+//
+// Watch() watches the *directories* containing files rather than the
+// files themselves. This matters because the standard "atomic save"
+// (write a temp file, then rename it over the target -- what vim, most
+// editors, and config-management tools do) replaces the file's inode;
+// a watch placed on the file itself would silently stop receiving
+// events the first time that happens. Watching the directory and
+// filtering by filename survives renames, removals, and recreation.
+//
+// onChange, if not nil, is called after every reload attempt with the
+// error ConfigureAll() returned (nil unless none of files exists any
+// more), and with any error reported by the watcher itself, so the
+// caller can log or alert without the watch loop crashing the program.
+// Errors in individual lines or values of a file are not passed to
+// onChange; with verbose set they are written to stderr.
+//
+// Watch() returns a stop function that should be called to shut down
+// the watch goroutine and release the underlying OS resources.
+//
+func (c *Config) Watch(files []string, verbose bool, env_prefix string, onChange func(error)) (func(), error) {
+    found := false
+    for _, fname := range files {
+        if _, err := os.Stat(fname); err == nil {
+            found = true
+            break
+        }
+    }
+    if !found {
+        return nil, errors.New("no configuration files found to watch")
+    }
+
+    watcher, err := fsnotify.NewWatcher()
+    if err != nil {
+        return nil, err
+    }
+
+    names := make(map[string]bool, len(files))
+    dirs  := make(map[string]bool, len(files))
+    for _, fname := range files {
+        names[filepath.Base(fname)] = true
+        dirs[filepath.Dir(fname)] = true
+    }
+
+    watched := 0
+    for dir := range dirs {
+        if err := watcher.Add(dir); err != nil {
+            if verbose {
+                fmt.Fprintf(os.Stderr, "not watching \"%s\": %s\n", dir, err)
+            }
+            continue
+        }
+        watched++
+    }
+    if watched == 0 {
+        watcher.Close()
+        return nil, errors.New("none of the configuration directories could be watched")
+    }
+
+    done := make(chan struct{})
+
+    go func() {
+        for {
+            select {
+            case event, ok := <-watcher.Events:
+                if !ok {
+                    return
+                }
+                if !names[filepath.Base(event.Name)] {
+                    continue
+                }
+                if event.Op & (fsnotify.Write | fsnotify.Create) != 0 {
+                    err := c.ConfigureAll(files, verbose, env_prefix)
+                    if onChange != nil {
+                        onChange(err)
+                    }
+                }
+            case err, ok := <-watcher.Errors:
+                if !ok {
+                    return
+                }
+                if onChange != nil {
+                    onChange(err)
+                }
+            case <-done:
+                return
+            }
+        }
+    }()
+
+    stop := func() {
+        close(done)
+        watcher.Close()
+    }
+
+    return stop, nil
+}
+
+// Watch() monitors configuration files for changes using the default
+// Config. See (*Config).Watch() for details.
+//
+func Watch(files []string, verbose bool, env_prefix string, onChange func(error)) (func(), error) {
+    return default_config.Watch(files, verbose, env_prefix, onChange)
+}